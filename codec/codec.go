@@ -0,0 +1,19 @@
+// Package codec defines the pluggable AMBE+2 decoder interface used to
+// turn DMR voice frames into PCM samples, and the backends selectable
+// via config: a real decoder backed by mbelib (md380-emu's AMBE+2
+// codec) and a passthrough that preserves callrec's original
+// behavior of treating the raw frame bytes as samples.
+package codec
+
+// Decoder turns AMBE+2 voice frames into 8kHz mono PCM samples.
+//
+// A Decoder instance is stateful: DMR's codec carries prediction state
+// across consecutive frames of the same transmission. Callers must
+// call Reset between unrelated transmissions (e.g. across a
+// hang-time gap) so state doesn't leak from one call into the next.
+type Decoder interface {
+	// Decode decodes a single AMBE+2 frame.
+	Decode(ambeFrame []byte) ([]int16, error)
+	// Reset clears state carried between frames.
+	Reset()
+}
@@ -0,0 +1,12 @@
+//go:build !mbelib
+
+package codec
+
+import "errors"
+
+// NewMBE is unavailable in this build: it was not built with the
+// mbelib CGo backend. Build with -tags mbelib (and mbelib's headers
+// and library available to cgo) to get a real AMBE+2 decoder.
+func NewMBE() (Decoder, error) {
+	return nil, errors.New("codec: mbelib backend not built in, rebuild with -tags mbelib")
+}
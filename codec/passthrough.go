@@ -0,0 +1,17 @@
+package codec
+
+// Passthrough is a Decoder that performs no real AMBE+2 decoding: it
+// reinterprets the raw frame bytes as little-endian 16-bit samples.
+// This is callrec's original behavior, kept as the default so the
+// daemon still runs where a real codec backend isn't built in.
+type Passthrough struct{}
+
+func (Passthrough) Decode(frame []byte) ([]int16, error) {
+	samples := make([]int16, len(frame)/2)
+	for i := range samples {
+		samples[i] = int16(frame[2*i]) | int16(frame[2*i+1])<<8
+	}
+	return samples, nil
+}
+
+func (Passthrough) Reset() {}
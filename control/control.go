@@ -0,0 +1,131 @@
+// Package control implements callrec's control plane: live status,
+// active-call listing, dynamic subscribe/unsubscribe, recording
+// start/stop, and a live audio tail.
+//
+// SCOPE NOTE: the original request asked for a gRPC service with a
+// grpc-gateway JSON-RPC front. This tree has no protoc toolchain or
+// vendored gRPC/protobuf dependencies available to generate and build
+// real stubs against, so what ships here is a hand-rolled JSON-over-HTTP
+// API covering the same methods instead (see http.go) -- there is no
+// gRPC service underneath it. That is a scope substitution, not
+// something to wave through: whoever owns this backlog should
+// explicitly confirm the JSON-only surface is acceptable, or this needs
+// to go back for a real gRPC/grpc-gateway implementation. Server.Handler
+// is written so a gRPC front end could be layered over the same
+// callbacks later without reworking this package.
+package control
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// ActiveCall describes a transmission currently in progress, as
+// reported by ListActiveCalls.
+type ActiveCall struct {
+	TG    uint32    `json:"tg"`
+	Src   uint32    `json:"src"`
+	Dst   uint32    `json:"dst"`
+	Start time.Time `json:"start"`
+}
+
+// Status is the daemon's current health, as reported by GetStatus.
+type Status struct {
+	LoggedIn    bool         `json:"logged_in"`
+	Uptime      float64      `json:"uptime_seconds"`
+	LastPacket  time.Time    `json:"last_packet"`
+	ActiveCalls []ActiveCall `json:"active_calls"`
+}
+
+// SinkSpec selects and configures where a talkgroup's decoded audio is
+// sent when recording is started via StartRecording.
+type SinkSpec struct {
+	Type   string `json:"type"` // "file", "udp" or "stdout"
+	Path   string `json:"path,omitempty"`
+	Target string `json:"target,omitempty"`
+}
+
+var errNotWired = errors.New("control: no handler wired up for this method")
+
+// Server implements the control plane. Its exported funcs are hooks
+// that main wires up to the daemon's actual state and connection; they
+// are called synchronously from the HTTP handlers in http.go.
+type Server struct {
+	GetStatus       func() Status
+	ListActiveCalls func() []ActiveCall
+	Subscribe       func(tg uint32, sessionType string) error
+	Unsubscribe     func(tg uint32) error
+	StartRecording  func(tg uint32, sink SinkSpec) error
+	StopRecording   func(tg uint32) error
+
+	broker *broker
+}
+
+// New creates a Server. The exported callback fields must be set by the
+// caller before the server starts handling requests.
+func New() *Server {
+	return &Server{broker: newBroker()}
+}
+
+// PublishAudio makes a decoded audio frame available to any active
+// TailAudio subscribers for its TG. It is safe to call unconditionally
+// from the packet-handling loop; frames for a TG with no subscribers
+// are dropped.
+func (s *Server) PublishAudio(tg, src, dst uint32, pcm []int16) {
+	s.broker.publish(audioFrame{TG: tg, Src: src, Dst: dst, PCM: pcm, Time: time.Now()})
+}
+
+type audioFrame struct {
+	TG, Src, Dst uint32
+	PCM          []int16
+	Time         time.Time
+}
+
+// broker fans out published audio frames to any number of per-TG
+// subscribers (TailAudio callers).
+type broker struct {
+	mu   sync.Mutex
+	subs map[uint32][]chan audioFrame
+}
+
+func newBroker() *broker {
+	return &broker{subs: make(map[uint32][]chan audioFrame)}
+}
+
+func (b *broker) publish(f audioFrame) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, ch := range b.subs[f.TG] {
+		select {
+		case ch <- f:
+		default:
+			// Slow subscriber; drop the frame rather than block the
+			// packet-handling loop.
+		}
+	}
+}
+
+// subscribe registers a new listener for tg's audio frames. The
+// returned cancel func must be called when the caller is done.
+func (b *broker) subscribe(tg uint32) (<-chan audioFrame, func()) {
+	ch := make(chan audioFrame, 32)
+
+	b.mu.Lock()
+	b.subs[tg] = append(b.subs[tg], ch)
+	b.mu.Unlock()
+
+	cancel := func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		chans := b.subs[tg]
+		for i, c := range chans {
+			if c == ch {
+				b.subs[tg] = append(chans[:i], chans[i+1:]...)
+				break
+			}
+		}
+		close(ch)
+	}
+	return ch, cancel
+}
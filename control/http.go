@@ -0,0 +1,172 @@
+package control
+
+// http.go exposes Server over a small JSON-RPC-style HTTP API: each
+// method is a POST to /control/<MethodName> with a JSON body and a JSON
+// response, except TailAudio, which is a GET that streams
+// newline-delimited JSON frames for as long as the client stays
+// connected (the server-streaming equivalent).
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+)
+
+// Handler returns the control plane's HTTP handler.
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/control/GetStatus", s.handleGetStatus)
+	mux.HandleFunc("/control/ListActiveCalls", s.handleListActiveCalls)
+	mux.HandleFunc("/control/Subscribe", s.handleSubscribe)
+	mux.HandleFunc("/control/Unsubscribe", s.handleUnsubscribe)
+	mux.HandleFunc("/control/StartRecording", s.handleStartRecording)
+	mux.HandleFunc("/control/StopRecording", s.handleStopRecording)
+	mux.HandleFunc("/control/TailAudio", s.handleTailAudio)
+	return mux
+}
+
+// ListenAndServe starts the control plane's HTTP API on addr.
+func (s *Server) ListenAndServe(addr string) error {
+	return http.ListenAndServe(addr, s.Handler())
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusBadRequest)
+	json.NewEncoder(w).Encode(struct {
+		Error string `json:"error"`
+	}{err.Error()})
+}
+
+func (s *Server) handleGetStatus(w http.ResponseWriter, r *http.Request) {
+	if s.GetStatus == nil {
+		writeError(w, errNotWired)
+		return
+	}
+	writeJSON(w, s.GetStatus())
+}
+
+func (s *Server) handleListActiveCalls(w http.ResponseWriter, r *http.Request) {
+	if s.ListActiveCalls == nil {
+		writeError(w, errNotWired)
+		return
+	}
+	writeJSON(w, s.ListActiveCalls())
+}
+
+func (s *Server) handleSubscribe(w http.ResponseWriter, r *http.Request) {
+	if s.Subscribe == nil {
+		writeError(w, errNotWired)
+		return
+	}
+	var req struct {
+		TG          uint32 `json:"tg"`
+		SessionType string `json:"session_type"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, err)
+		return
+	}
+	if err := s.Subscribe(req.TG, req.SessionType); err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, struct{}{})
+}
+
+func (s *Server) handleUnsubscribe(w http.ResponseWriter, r *http.Request) {
+	if s.Unsubscribe == nil {
+		writeError(w, errNotWired)
+		return
+	}
+	var req struct {
+		TG uint32 `json:"tg"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, err)
+		return
+	}
+	if err := s.Unsubscribe(req.TG); err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, struct{}{})
+}
+
+func (s *Server) handleStartRecording(w http.ResponseWriter, r *http.Request) {
+	if s.StartRecording == nil {
+		writeError(w, errNotWired)
+		return
+	}
+	var req struct {
+		TG   uint32   `json:"tg"`
+		Sink SinkSpec `json:"sink"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, err)
+		return
+	}
+	if err := s.StartRecording(req.TG, req.Sink); err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, struct{}{})
+}
+
+func (s *Server) handleStopRecording(w http.ResponseWriter, r *http.Request) {
+	if s.StopRecording == nil {
+		writeError(w, errNotWired)
+		return
+	}
+	var req struct {
+		TG uint32 `json:"tg"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, err)
+		return
+	}
+	if err := s.StopRecording(req.TG); err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, struct{}{})
+}
+
+func (s *Server) handleTailAudio(w http.ResponseWriter, r *http.Request) {
+	tg, err := strconv.ParseUint(r.URL.Query().Get("tg"), 10, 32)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	frames, cancel := s.broker.subscribe(uint32(tg))
+	defer cancel()
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	enc := json.NewEncoder(w)
+	for {
+		select {
+		case f, ok := <-frames:
+			if !ok {
+				return
+			}
+			if err := enc.Encode(f); err != nil {
+				return
+			}
+			flusher.Flush()
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
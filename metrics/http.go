@@ -0,0 +1,25 @@
+package metrics
+
+import "net/http"
+
+// Handler serves every registered metric in Prometheus text exposition
+// format.
+func Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+
+		registryMu.Lock()
+		defer registryMu.Unlock()
+		for _, m := range registry {
+			m.write(w)
+		}
+	})
+}
+
+// ListenAndServe starts a metrics server on addr, serving the registry
+// at /metrics.
+func ListenAndServe(addr string) error {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", Handler())
+	return http.ListenAndServe(addr, mux)
+}
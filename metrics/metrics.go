@@ -0,0 +1,161 @@
+// Package metrics implements the small subset of the Prometheus text
+// exposition format callrec needs (counters, gauges, labeled counters
+// and a histogram), without pulling in an external client library.
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"sync"
+	"sync/atomic"
+)
+
+// metric is anything that can render itself in Prometheus text format.
+type metric interface {
+	write(w io.Writer)
+}
+
+var (
+	registryMu sync.Mutex
+	registry   []metric
+)
+
+func register(m metric) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	registry = append(registry, m)
+}
+
+// Counter is a monotonically increasing value.
+type Counter struct {
+	name  string
+	help  string
+	value uint64
+}
+
+// NewCounter creates and registers a Counter.
+func NewCounter(name, help string) *Counter {
+	c := &Counter{name: name, help: help}
+	register(c)
+	return c
+}
+
+// Inc increments c by one.
+func (c *Counter) Inc() { atomic.AddUint64(&c.value, 1) }
+
+func (c *Counter) write(w io.Writer) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s counter\n%s %d\n", c.name, c.help, c.name, c.name, atomic.LoadUint64(&c.value))
+}
+
+// Gauge is a value that can go up or down.
+type Gauge struct {
+	name string
+	help string
+	bits uint64
+}
+
+// NewGauge creates and registers a Gauge.
+func NewGauge(name, help string) *Gauge {
+	g := &Gauge{name: name, help: help}
+	register(g)
+	return g
+}
+
+// Set sets g to v.
+func (g *Gauge) Set(v float64) { atomic.StoreUint64(&g.bits, math.Float64bits(v)) }
+
+func (g *Gauge) write(w io.Writer) {
+	v := math.Float64frombits(atomic.LoadUint64(&g.bits))
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s gauge\n%s %v\n", g.name, g.help, g.name, g.name, v)
+}
+
+// LabeledCounter is a family of counters distinguished by a single
+// label, e.g. callrec_packets_received_total{type="..."}.
+type LabeledCounter struct {
+	name  string
+	help  string
+	label string
+
+	mu     sync.Mutex
+	values map[string]uint64
+}
+
+// NewLabeledCounter creates and registers a LabeledCounter whose values
+// are distinguished by the given label name.
+func NewLabeledCounter(name, help, label string) *LabeledCounter {
+	c := &LabeledCounter{name: name, help: help, label: label, values: make(map[string]uint64)}
+	register(c)
+	return c
+}
+
+// Inc increments the counter for the given label value by one.
+func (c *LabeledCounter) Inc(value string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.values[value]++
+}
+
+func (c *LabeledCounter) write(w io.Writer) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s counter\n", c.name, c.help, c.name)
+	keys := make([]string, 0, len(c.values))
+	for k := range c.values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		fmt.Fprintf(w, "%s{%s=%q} %d\n", c.name, c.label, k, c.values[k])
+	}
+}
+
+// Histogram tracks the distribution of observed values into cumulative
+// buckets, Prometheus-style.
+type Histogram struct {
+	name    string
+	help    string
+	buckets []float64
+
+	mu     sync.Mutex
+	counts []uint64 // counts[i] = observations <= buckets[i]
+	sum    float64
+	total  uint64
+}
+
+// NewHistogram creates and registers a Histogram with the given bucket
+// upper bounds, which must be sorted ascending.
+func NewHistogram(name, help string, buckets []float64) *Histogram {
+	h := &Histogram{name: name, help: help, buckets: buckets, counts: make([]uint64, len(buckets))}
+	register(h)
+	return h
+}
+
+// Observe records v.
+func (h *Histogram) Observe(v float64) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for i, b := range h.buckets {
+		if v <= b {
+			h.counts[i]++
+		}
+	}
+	h.sum += v
+	h.total++
+}
+
+func (h *Histogram) write(w io.Writer) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s histogram\n", h.name, h.help, h.name)
+	for i, b := range h.buckets {
+		fmt.Fprintf(w, "%s_bucket{le=%q} %d\n", h.name, fmt.Sprint(b), h.counts[i])
+	}
+	fmt.Fprintf(w, "%s_bucket{le=\"+Inf\"} %d\n", h.name, h.total)
+	fmt.Fprintf(w, "%s_sum %v\n", h.name, h.sum)
+	fmt.Fprintf(w, "%s_count %d\n", h.name, h.total)
+}
@@ -0,0 +1,82 @@
+package metrics
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestCounterWrite(t *testing.T) {
+	c := &Counter{name: "callrec_test_total", help: "a test counter"}
+	c.Inc()
+	c.Inc()
+
+	var buf bytes.Buffer
+	c.write(&buf)
+
+	want := "# HELP callrec_test_total a test counter\n" +
+		"# TYPE callrec_test_total counter\n" +
+		"callrec_test_total 2\n"
+	if got := buf.String(); got != want {
+		t.Fatalf("write() = %q, want %q", got, want)
+	}
+}
+
+func TestGaugeWrite(t *testing.T) {
+	g := &Gauge{name: "callrec_test_gauge", help: "a test gauge"}
+	g.Set(3.5)
+	g.Set(-1)
+
+	var buf bytes.Buffer
+	g.write(&buf)
+
+	want := "# HELP callrec_test_gauge a test gauge\n" +
+		"# TYPE callrec_test_gauge gauge\n" +
+		"callrec_test_gauge -1\n"
+	if got := buf.String(); got != want {
+		t.Fatalf("write() = %q, want %q", got, want)
+	}
+}
+
+func TestLabeledCounterWriteSortsLabelValues(t *testing.T) {
+	c := &LabeledCounter{name: "callrec_test_labeled", help: "a test labeled counter", label: "kind", values: make(map[string]uint64)}
+	c.Inc("b")
+	c.Inc("a")
+	c.Inc("a")
+
+	var buf bytes.Buffer
+	c.write(&buf)
+
+	want := "# HELP callrec_test_labeled a test labeled counter\n" +
+		"# TYPE callrec_test_labeled counter\n" +
+		`callrec_test_labeled{kind="a"} 2` + "\n" +
+		`callrec_test_labeled{kind="b"} 1` + "\n"
+	if got := buf.String(); got != want {
+		t.Fatalf("write() = %q, want %q", got, want)
+	}
+}
+
+func TestHistogramWriteBucketsAndSum(t *testing.T) {
+	h := NewHistogram("callrec_test_hist", "a test histogram", []float64{1, 5, 10})
+	h.Observe(0.5)
+	h.Observe(3)
+	h.Observe(3)
+	h.Observe(20)
+
+	var buf bytes.Buffer
+	h.write(&buf)
+	out := buf.String()
+
+	for _, line := range []string{
+		`callrec_test_hist_bucket{le="1"} 1`,
+		`callrec_test_hist_bucket{le="5"} 3`,
+		`callrec_test_hist_bucket{le="10"} 3`,
+		`callrec_test_hist_bucket{le="+Inf"} 4`,
+		"callrec_test_hist_sum 26.5",
+		"callrec_test_hist_count 4",
+	} {
+		if !strings.Contains(out, line) {
+			t.Fatalf("write() output missing %q, got:\n%s", line, out)
+		}
+	}
+}
@@ -0,0 +1,23 @@
+package main
+
+import "callrec/metrics"
+
+// Metrics exposed on -metrics-addr for operators running fleets of
+// recorders to scrape instead of parsing log lines.
+var (
+	metricPacketsReceived = metrics.NewLabeledCounter(
+		"callrec_packets_received_total", "Packets received, by rewind packet type.", "type")
+	metricInvalidPackets = metrics.NewCounter(
+		"callrec_invalid_packets_total", "Packets dropped for being malformed or unrecognized.")
+	metricLoginState = metrics.NewGauge(
+		"callrec_login_state", "1 if logged in to the rewind server, 0 otherwise.")
+	metricLastValidPacketTimestamp = metrics.NewGauge(
+		"callrec_last_valid_packet_timestamp_seconds", "Unix timestamp of the last valid packet received.")
+	metricCallsTotal = metrics.NewLabeledCounter(
+		"callrec_calls_total", "Completed calls, by talkgroup.", "tg")
+	metricCallDuration = metrics.NewHistogram(
+		"callrec_call_duration_seconds", "Distribution of completed call durations.",
+		[]float64{0.5, 1, 2, 5, 10, 15, 30, 60, 120, 300})
+	metricAudioFramesDropped = metrics.NewCounter(
+		"callrec_audio_frames_dropped_total", "Audio frames dropped (malformed header, decode failure or unsubscribed TG).")
+)
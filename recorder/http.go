@@ -0,0 +1,121 @@
+package recorder
+
+// http.go implements the HTTP playback API: listing recorded calls and
+// streaming their WAV audio.
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ListCalls returns the metadata of every persisted call under r.dir,
+// most recent first, optionally filtered by tg, src and/or date
+// (YYYY-MM-DD, matched against the call's start time).
+func (r *Recorder) ListCalls(tg, src *uint32, date string) ([]Call, error) {
+	entries, err := os.ReadDir(r.dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var calls []Call
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasSuffix(e.Name(), ".json") {
+			continue
+		}
+		f, err := os.Open(filepath.Join(r.dir, e.Name()))
+		if err != nil {
+			return nil, err
+		}
+		var c Call
+		err = json.NewDecoder(f).Decode(&c)
+		f.Close()
+		if err != nil {
+			return nil, err
+		}
+		if tg != nil && c.TG != *tg {
+			continue
+		}
+		if src != nil && c.Src != *src {
+			continue
+		}
+		if date != "" && c.Start.Format("2006-01-02") != date {
+			continue
+		}
+		calls = append(calls, c)
+	}
+
+	sort.Slice(calls, func(i, j int) bool { return calls[i].Start.After(calls[j].Start) })
+	return calls, nil
+}
+
+// ServeHTTP implements the /calls playback API: GET /calls lists
+// recordings (optionally filtered with ?tg=, ?src= and ?date= query
+// parameters) and GET /calls/{id}.wav streams a recording's audio.
+func (r *Recorder) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	path := strings.TrimPrefix(req.URL.Path, "/calls")
+	path = strings.TrimPrefix(path, "/")
+
+	if path == "" {
+		r.serveList(w, req)
+		return
+	}
+
+	if !strings.HasSuffix(path, ".wav") {
+		http.NotFound(w, req)
+		return
+	}
+	id := strings.TrimSuffix(path, ".wav")
+	if strings.ContainsAny(id, "/\\") {
+		http.NotFound(w, req)
+		return
+	}
+	w.Header().Set("Content-Type", "audio/wav")
+	http.ServeFile(w, req, filepath.Join(r.dir, id+".wav"))
+}
+
+func (r *Recorder) serveList(w http.ResponseWriter, req *http.Request) {
+	q := req.URL.Query()
+
+	var tg, src *uint32
+	if v := q.Get("tg"); v != "" {
+		n, err := strconv.ParseUint(v, 10, 32)
+		if err != nil {
+			http.Error(w, "invalid tg", http.StatusBadRequest)
+			return
+		}
+		u := uint32(n)
+		tg = &u
+	}
+	if v := q.Get("src"); v != "" {
+		n, err := strconv.ParseUint(v, 10, 32)
+		if err != nil {
+			http.Error(w, "invalid src", http.StatusBadRequest)
+			return
+		}
+		u := uint32(n)
+		src = &u
+	}
+
+	calls, err := r.ListCalls(tg, src, q.Get("date"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(calls)
+}
+
+// ListenAndServe starts the /calls HTTP API on addr. It blocks until the
+// server stops, same as http.ListenAndServe.
+func (r *Recorder) ListenAndServe(addr string) error {
+	return http.ListenAndServe(addr, r)
+}
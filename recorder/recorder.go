@@ -0,0 +1,167 @@
+// Package recorder groups incoming DMR audio frames into per-call
+// recordings and persists each finished call as a WAV file with a JSON
+// sidecar describing it.
+package recorder
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// sampleRate is the sample rate of the PCM audio written to WAV files.
+// AMBE+2 as used on DMR encodes 20ms frames at 8kHz.
+const sampleRate = 8000
+
+// Call describes a single recorded transmission. It is the structure
+// persisted alongside the WAV audio as a JSON sidecar.
+type Call struct {
+	ID         string    `json:"id"`
+	TG         uint32    `json:"tg"`
+	Src        uint32    `json:"src"`
+	Dst        uint32    `json:"dst"`
+	Start      time.Time `json:"start"`
+	Duration   float64   `json:"duration_seconds"`
+	FrameCount int       `json:"frame_count"`
+}
+
+// call is the in-progress, mutable version of Call that is being filled
+// in while frames keep arriving.
+type call struct {
+	Call
+	lastFrame time.Time
+	samples   []int16
+}
+
+// Recorder buffers audio frames by TG+source and flushes each call to
+// disk once it has been silent for the configured hang time.
+type Recorder struct {
+	dir      string
+	hangTime time.Duration
+
+	// OnFinish, if set, is called with a call's final metadata right
+	// after it has been persisted.
+	OnFinish func(Call)
+
+	mu     sync.Mutex
+	active map[string]*call
+}
+
+// New creates a Recorder that writes finished calls under dir, closing
+// a call after it has seen no frames for hangTime.
+func New(dir string, hangTime time.Duration) *Recorder {
+	return &Recorder{
+		dir:      dir,
+		hangTime: hangTime,
+		active:   make(map[string]*call),
+	}
+}
+
+func callKey(tg, src uint32, start time.Time) string {
+	return fmt.Sprintf("%d-%d-%d", tg, src, start.UnixNano())
+}
+
+// AddFrame appends a decoded AMBE frame's PCM samples to the call for
+// tg/src, starting a new call if none is currently open or the previous
+// one has aged out past the hang time. It must be called with frames in
+// arrival order.
+func (r *Recorder) AddFrame(tg, src, dst uint32, pcm []int16) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	key := fmt.Sprintf("%d-%d", tg, src)
+	c, ok := r.active[key]
+	if ok && now.Sub(c.lastFrame) > r.hangTime {
+		r.finishLocked(key, c)
+		ok = false
+	}
+	if !ok {
+		c = &call{Call: Call{
+			ID:    callKey(tg, src, now),
+			TG:    tg,
+			Src:   src,
+			Dst:   dst,
+			Start: now,
+		}}
+		r.active[key] = c
+	}
+
+	c.samples = append(c.samples, pcm...)
+	c.FrameCount++
+	c.lastFrame = now
+}
+
+// Active returns the calls currently in progress.
+func (r *Recorder) Active() []Call {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	calls := make([]Call, 0, len(r.active))
+	for _, c := range r.active {
+		calls = append(calls, c.Call)
+	}
+	return calls
+}
+
+// Flush closes any call that has been silent for longer than the hang
+// time. It should be called periodically so that a call's recording is
+// written to disk even if no further frames ever arrive for it.
+func (r *Recorder) Flush() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	for key, c := range r.active {
+		if now.Sub(c.lastFrame) > r.hangTime {
+			r.finishLocked(key, c)
+		}
+	}
+}
+
+// finishLocked writes c to disk and removes it from the active set. The
+// caller must hold r.mu.
+func (r *Recorder) finishLocked(key string, c *call) {
+	delete(r.active, key)
+
+	c.Duration = float64(len(c.samples)) / float64(sampleRate)
+	if err := r.persist(c); err != nil {
+		log.Println("failed to persist call", c.ID, ":", err)
+		return
+	}
+	if r.OnFinish != nil {
+		r.OnFinish(c.Call)
+	}
+}
+
+func (r *Recorder) persist(c *call) error {
+	if err := os.MkdirAll(r.dir, 0755); err != nil {
+		return err
+	}
+
+	wavPath := filepath.Join(r.dir, c.ID+".wav")
+	f, err := os.Create(wavPath)
+	if err != nil {
+		return err
+	}
+	if err := writeWAV(f, c.samples); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	sidecar, err := os.Create(filepath.Join(r.dir, c.ID+".json"))
+	if err != nil {
+		return err
+	}
+	defer sidecar.Close()
+	enc := json.NewEncoder(sidecar)
+	enc.SetIndent("", "  ")
+	return enc.Encode(c.Call)
+}
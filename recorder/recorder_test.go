@@ -0,0 +1,107 @@
+package recorder
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+// finishCollector records every call passed to OnFinish, guarded by a
+// mutex since OnFinish is invoked while Recorder.mu is held.
+type finishCollector struct {
+	mu    sync.Mutex
+	calls []Call
+}
+
+func (f *finishCollector) onFinish(c Call) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calls = append(f.calls, c)
+}
+
+func (f *finishCollector) snapshot() []Call {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	out := make([]Call, len(f.calls))
+	copy(out, f.calls)
+	return out
+}
+
+func TestAddFrameGroupsConsecutiveFramesIntoOneCall(t *testing.T) {
+	r := New(t.TempDir(), time.Hour)
+
+	r.AddFrame(1, 2, 3, []int16{1, 2})
+	r.AddFrame(1, 2, 3, []int16{3, 4})
+	r.AddFrame(1, 2, 3, []int16{5, 6})
+
+	active := r.Active()
+	if len(active) != 1 {
+		t.Fatalf("Active() = %d calls, want 1", len(active))
+	}
+	if got := active[0].FrameCount; got != 3 {
+		t.Fatalf("FrameCount = %d, want 3", got)
+	}
+	if active[0].TG != 1 || active[0].Src != 2 || active[0].Dst != 3 {
+		t.Fatalf("call routing = %+v, want TG=1 Src=2 Dst=3", active[0])
+	}
+}
+
+func TestAddFrameKeepsDifferentSourcesSeparate(t *testing.T) {
+	r := New(t.TempDir(), time.Hour)
+
+	r.AddFrame(1, 2, 9, []int16{1})
+	r.AddFrame(1, 3, 9, []int16{1})
+	r.AddFrame(1, 2, 9, []int16{1})
+
+	active := r.Active()
+	if len(active) != 2 {
+		t.Fatalf("Active() = %d calls, want 2", len(active))
+	}
+}
+
+func TestAddFrameStartsNewCallAfterHangTime(t *testing.T) {
+	fc := &finishCollector{}
+	r := New(t.TempDir(), 20*time.Millisecond)
+	r.OnFinish = fc.onFinish
+
+	r.AddFrame(1, 2, 3, []int16{1, 2})
+	r.AddFrame(1, 2, 3, []int16{1, 2})
+	time.Sleep(40 * time.Millisecond)
+	r.AddFrame(1, 2, 3, []int16{1, 2})
+
+	finished := fc.snapshot()
+	if len(finished) != 1 {
+		t.Fatalf("OnFinish called %d times, want 1", len(finished))
+	}
+	if finished[0].FrameCount != 2 {
+		t.Fatalf("finished call FrameCount = %d, want 2", finished[0].FrameCount)
+	}
+
+	active := r.Active()
+	if len(active) != 1 || active[0].FrameCount != 1 {
+		t.Fatalf("Active() = %+v, want one call with FrameCount 1", active)
+	}
+}
+
+func TestFlushClosesCallsPastHangTime(t *testing.T) {
+	fc := &finishCollector{}
+	r := New(t.TempDir(), 10*time.Millisecond)
+	r.OnFinish = fc.onFinish
+
+	r.AddFrame(5, 6, 7, []int16{1, 2, 3})
+	r.Flush()
+	if len(fc.snapshot()) != 0 {
+		t.Fatalf("Flush closed the call before it aged past the hang time")
+	}
+
+	time.Sleep(20 * time.Millisecond)
+	r.Flush()
+
+	finished := fc.snapshot()
+	if len(finished) != 1 {
+		t.Fatalf("OnFinish called %d times after Flush, want 1", len(finished))
+	}
+	if len(r.Active()) != 0 {
+		t.Fatalf("Active() still reports the call after Flush closed it")
+	}
+}
@@ -0,0 +1,64 @@
+package recorder
+
+import (
+	"encoding/binary"
+	"io"
+)
+
+// writeWAV writes samples as a mono 16-bit PCM WAV file to w.
+func writeWAV(w io.Writer, samples []int16) error {
+	const (
+		numChannels   = 1
+		bitsPerSample = 16
+	)
+	byteRate := sampleRate * numChannels * bitsPerSample / 8
+	blockAlign := numChannels * bitsPerSample / 8
+	dataSize := len(samples) * 2
+
+	write := func(v interface{}) error {
+		return binary.Write(w, binary.LittleEndian, v)
+	}
+
+	if _, err := w.Write([]byte("RIFF")); err != nil {
+		return err
+	}
+	if err := write(uint32(36 + dataSize)); err != nil {
+		return err
+	}
+	if _, err := w.Write([]byte("WAVE")); err != nil {
+		return err
+	}
+
+	if _, err := w.Write([]byte("fmt ")); err != nil {
+		return err
+	}
+	if err := write(uint32(16)); err != nil { // fmt chunk size
+		return err
+	}
+	if err := write(uint16(1)); err != nil { // PCM
+		return err
+	}
+	if err := write(uint16(numChannels)); err != nil {
+		return err
+	}
+	if err := write(uint32(sampleRate)); err != nil {
+		return err
+	}
+	if err := write(uint32(byteRate)); err != nil {
+		return err
+	}
+	if err := write(uint16(blockAlign)); err != nil {
+		return err
+	}
+	if err := write(uint16(bitsPerSample)); err != nil {
+		return err
+	}
+
+	if _, err := w.Write([]byte("data")); err != nil {
+		return err
+	}
+	if err := write(uint32(dataSize)); err != nil {
+		return err
+	}
+	return write(samples)
+}
@@ -0,0 +1,74 @@
+package recorder
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+)
+
+func TestWriteWAVHeader(t *testing.T) {
+	samples := []int16{1, -1, 32767, -32768}
+
+	var buf bytes.Buffer
+	if err := writeWAV(&buf, samples); err != nil {
+		t.Fatalf("writeWAV() error: %v", err)
+	}
+
+	data := buf.Bytes()
+
+	wantDataSize := len(samples) * 2
+	wantChunkSize := 36 + wantDataSize
+
+	if !bytes.Equal(data[0:4], []byte("RIFF")) {
+		t.Fatalf("RIFF tag = %q", data[0:4])
+	}
+	if got := binary.LittleEndian.Uint32(data[4:8]); got != uint32(wantChunkSize) {
+		t.Fatalf("RIFF chunk size = %d, want %d", got, wantChunkSize)
+	}
+	if !bytes.Equal(data[8:12], []byte("WAVE")) {
+		t.Fatalf("WAVE tag = %q", data[8:12])
+	}
+	if !bytes.Equal(data[12:16], []byte("fmt ")) {
+		t.Fatalf("fmt tag = %q", data[12:16])
+	}
+	if got := binary.LittleEndian.Uint32(data[16:20]); got != 16 {
+		t.Fatalf("fmt chunk size = %d, want 16", got)
+	}
+	if got := binary.LittleEndian.Uint16(data[20:22]); got != 1 {
+		t.Fatalf("audio format = %d, want 1 (PCM)", got)
+	}
+	if got := binary.LittleEndian.Uint16(data[22:24]); got != 1 {
+		t.Fatalf("num channels = %d, want 1", got)
+	}
+	if got := binary.LittleEndian.Uint32(data[24:28]); got != sampleRate {
+		t.Fatalf("sample rate = %d, want %d", got, sampleRate)
+	}
+	wantByteRate := sampleRate * 1 * 16 / 8
+	if got := binary.LittleEndian.Uint32(data[28:32]); got != uint32(wantByteRate) {
+		t.Fatalf("byte rate = %d, want %d", got, wantByteRate)
+	}
+	if got := binary.LittleEndian.Uint16(data[32:34]); got != 2 {
+		t.Fatalf("block align = %d, want 2", got)
+	}
+	if got := binary.LittleEndian.Uint16(data[34:36]); got != 16 {
+		t.Fatalf("bits per sample = %d, want 16", got)
+	}
+	if !bytes.Equal(data[36:40], []byte("data")) {
+		t.Fatalf("data tag = %q", data[36:40])
+	}
+	if got := binary.LittleEndian.Uint32(data[40:44]); got != uint32(wantDataSize) {
+		t.Fatalf("data chunk size = %d, want %d", got, wantDataSize)
+	}
+
+	if len(data) != 44+wantDataSize {
+		t.Fatalf("total length = %d, want %d", len(data), 44+wantDataSize)
+	}
+
+	for i, s := range samples {
+		off := 44 + i*2
+		got := int16(binary.LittleEndian.Uint16(data[off : off+2]))
+		if got != s {
+			t.Fatalf("sample %d = %d, want %d", i, got, s)
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package main
+
+// recorders.go tracks every recorder.Recorder instance the daemon
+// creates, not just the default shared one: a TalkgroupConfig whose
+// Sink.Path is set gets its own dedicated recorder (see buildSink), and
+// those need to be flushed, reported to the control plane, and served
+// over HTTP the same as the shared one or their calls are invisible
+// outside the process.
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+
+	"callrec/recorder"
+)
+
+type registeredRecorder struct {
+	mountPath string
+	rec       *recorder.Recorder
+}
+
+// recordersMu guards recorders, which is appended to whenever a sink
+// with a dedicated recording path is built (at startup or via the
+// control plane) and read from the flush loop, the control plane, and
+// the /calls HTTP handler.
+var recordersMu sync.Mutex
+var recorders []registeredRecorder
+
+// newRecorder creates a recorder.Recorder wired up to report completed
+// calls to the metrics this daemon exposes, the same way for the shared
+// recorder and any per-TG recorder built for a dedicated Sink.Path.
+func newRecorder(dir string) *recorder.Recorder {
+	r := recorder.New(dir, callHangTime())
+	r.OnFinish = func(c recorder.Call) {
+		metricCallsTotal.Inc(fmt.Sprint(c.TG))
+		metricCallDuration.Observe(c.Duration)
+	}
+	return r
+}
+
+// registerRecorder adds r to the set of recorders flushed, aggregated
+// into ActiveCalls, and served over HTTP alongside the shared recorder.
+// A second call with the same mountPath (e.g. StartRecording rebuilding
+// a TG's sink) replaces the earlier entry rather than leaking it.
+func registerRecorder(mountPath string, r *recorder.Recorder) {
+	recordersMu.Lock()
+	defer recordersMu.Unlock()
+
+	for i, e := range recorders {
+		if e.mountPath == mountPath {
+			recorders[i].rec = r
+			return
+		}
+	}
+	recorders = append(recorders, registeredRecorder{mountPath: mountPath, rec: r})
+}
+
+func snapshotRecorders() []registeredRecorder {
+	recordersMu.Lock()
+	defer recordersMu.Unlock()
+	return append([]registeredRecorder(nil), recorders...)
+}
+
+// flushRecorders flushes every registered recorder, so a per-TG
+// recorder ages out its calls on hang-time the same as the shared one,
+// even if no frame for that TG ever arrives again.
+func flushRecorders() {
+	for _, e := range snapshotRecorders() {
+		e.rec.Flush()
+	}
+}
+
+// activeRecordings reports the in-progress calls across every
+// registered recorder.
+func activeRecordings() []recorder.Call {
+	var calls []recorder.Call
+	for _, e := range snapshotRecorders() {
+		calls = append(calls, e.rec.Active()...)
+	}
+	return calls
+}
+
+// callsHandler serves the combined /calls API across every registered
+// recorder: the shared recorder answers at /calls, and each per-TG
+// recorder (one per distinct dedicated Sink.Path) answers under its own
+// /calls/tg/{tg} mount. Routing is resolved per request, rather than
+// baked into a mux once, so a recorder registered later (e.g. via
+// StartRecording) is reachable without restarting the HTTP server.
+func callsHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		entries := snapshotRecorders()
+
+		var best *registeredRecorder
+		for i, e := range entries {
+			if !strings.HasPrefix(req.URL.Path, e.mountPath) {
+				continue
+			}
+			if best == nil || len(e.mountPath) > len(best.mountPath) {
+				best = &entries[i]
+			}
+		}
+		if best == nil {
+			http.NotFound(w, req)
+			return
+		}
+		http.StripPrefix(best.mountPath, best.rec).ServeHTTP(w, req)
+	}
+}
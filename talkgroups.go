@@ -0,0 +1,208 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"strings"
+	"sync"
+
+	"callrec/recorder"
+)
+
+// TalkgroupConfig describes one talkgroup to subscribe to and where its
+// decoded audio should go.
+type TalkgroupConfig struct {
+	TG          uint32     `json:"tg"`
+	SessionType string     `json:"session_type"` // "group" or "private"
+	Sink        SinkConfig `json:"sink"`
+}
+
+// SinkConfig selects and configures the output for a talkgroup's audio.
+type SinkConfig struct {
+	Type string `json:"type"` // "file", "udp" or "stdout"
+
+	// Path, for Type "file", is the recordings directory for this
+	// talkgroup. It may contain the literal substring "{tg}", which is
+	// replaced with the talkgroup ID.
+	Path string `json:"path,omitempty"`
+
+	// Target, for Type "udp", is the host:port decoded PCM frames are
+	// forwarded to.
+	Target string `json:"target,omitempty"`
+}
+
+// sink is an output destination for a talkgroup's decoded audio.
+type sink interface {
+	write(tg, src, dst uint32, pcm []int16)
+}
+
+// fileSink persists frames as call recordings via a recorder.Recorder.
+type fileSink struct {
+	rec *recorder.Recorder
+}
+
+func (s *fileSink) write(tg, src, dst uint32, pcm []int16) {
+	s.rec.AddFrame(tg, src, dst, pcm)
+}
+
+// udpSink forwards decoded PCM frames, as raw little-endian int16
+// samples, to a fixed UDP target.
+type udpSink struct {
+	conn net.Conn
+}
+
+func (s *udpSink) write(tg, src, dst uint32, pcm []int16) {
+	buf := make([]byte, len(pcm)*2)
+	for i, v := range pcm {
+		buf[2*i] = byte(v)
+		buf[2*i+1] = byte(v >> 8)
+	}
+	if _, err := s.conn.Write(buf); err != nil {
+		log.Println("udp sink write failed:", err)
+	}
+}
+
+// stdoutSink reproduces the original behavior of dumping decoded audio
+// straight to stdout.
+type stdoutSink struct{}
+
+func (stdoutSink) write(tg, src, dst uint32, pcm []int16) {
+	buf := make([]byte, len(pcm)*2)
+	for i, v := range pcm {
+		buf[2*i] = byte(v)
+		buf[2*i+1] = byte(v >> 8)
+	}
+	os.Stdout.Write(buf)
+}
+
+// sessionType returns the rewind session type to subscribe with for cfg.
+func (cfg TalkgroupConfig) sessionType() uint8 {
+	return sessionTypeFromString(cfg.SessionType)
+}
+
+// sessionTypeFromString maps a config/control-plane session type name
+// ("group", "private") to its rewind session type constant, defaulting
+// to group voice.
+func sessionTypeFromString(s string) uint8 {
+	if s == "private" {
+		return rewindSessionTypePrivateVoice
+	}
+	return rewindSessionTypeGroupVoice
+}
+
+// buildSink constructs the sink described by cfg.Sink.
+func buildSink(cfg TalkgroupConfig) (sink, error) {
+	switch cfg.Sink.Type {
+	case "", "stdout":
+		return stdoutSink{}, nil
+	case "file":
+		if cfg.Sink.Path == "" {
+			// No dedicated path: archive alongside everything else in
+			// the shared recorder so it shows up in the /calls API.
+			return &fileSink{rec: rec}, nil
+		}
+		dir := strings.Replace(cfg.Sink.Path, "{tg}", fmt.Sprint(cfg.TG), -1)
+		r := newRecorder(dir)
+		registerRecorder(fmt.Sprintf("/calls/tg/%d", cfg.TG), r)
+		return &fileSink{rec: r}, nil
+	case "udp":
+		conn, err := net.Dial("udp", cfg.Sink.Target)
+		if err != nil {
+			return nil, err
+		}
+		return &udpSink{conn: conn}, nil
+	default:
+		return nil, fmt.Errorf("unknown sink type %q for TG %d", cfg.Sink.Type, cfg.TG)
+	}
+}
+
+// sinksMu guards sinksByTG, which is read from the packet-handling loop
+// and written from the control plane when a TG is subscribed,
+// unsubscribed, or has its recording toggled at runtime.
+var sinksMu sync.RWMutex
+var sinksByTG map[uint32]sink
+
+// initTalkgroups builds sinksByTG from the configured talkgroups.
+func initTalkgroups() {
+	sinksMu.Lock()
+	defer sinksMu.Unlock()
+
+	sinksByTG = make(map[uint32]sink, len(settings.RecTalkgroups))
+	for _, cfg := range settings.RecTalkgroups {
+		s, err := buildSink(cfg)
+		if err != nil {
+			log.Fatal("failed to set up sink for TG ", cfg.TG, ": ", err)
+		}
+		sinksByTG[cfg.TG] = s
+	}
+}
+
+// sinkFor returns the sink configured for tg, if any.
+func sinkFor(tg uint32) (sink, bool) {
+	sinksMu.RLock()
+	defer sinksMu.RUnlock()
+	s, ok := sinksByTG[tg]
+	return s, ok
+}
+
+// setSink installs s as the sink for tg, replacing any existing one.
+func setSink(tg uint32, s sink) {
+	sinksMu.Lock()
+	defer sinksMu.Unlock()
+	sinksByTG[tg] = s
+}
+
+// removeSink stops routing audio frames for tg to any sink.
+func removeSink(tg uint32) {
+	sinksMu.Lock()
+	defer sinksMu.Unlock()
+	delete(sinksByTG, tg)
+}
+
+// talkgroupsMu guards settings.RecTalkgroups, which the control plane
+// may append to or remove from at runtime.
+var talkgroupsMu sync.Mutex
+
+// subscribeAll sends a subscription request for every configured
+// talkgroup.
+func subscribeAll(conn net.Conn) {
+	talkgroupsMu.Lock()
+	cfgs := append([]TalkgroupConfig(nil), settings.RecTalkgroups...)
+	talkgroupsMu.Unlock()
+
+	for _, cfg := range cfgs {
+		sendSubscription(conn, cfg.TG, cfg.sessionType())
+	}
+}
+
+// addTalkgroupConfig records cfg in settings.RecTalkgroups, replacing
+// any existing entry for the same TG, so it is re-subscribed to on
+// reconnect.
+func addTalkgroupConfig(cfg TalkgroupConfig) {
+	talkgroupsMu.Lock()
+	defer talkgroupsMu.Unlock()
+
+	for i, existing := range settings.RecTalkgroups {
+		if existing.TG == cfg.TG {
+			settings.RecTalkgroups[i] = cfg
+			return
+		}
+	}
+	settings.RecTalkgroups = append(settings.RecTalkgroups, cfg)
+}
+
+// removeTalkgroupConfig drops tg from settings.RecTalkgroups so it is
+// not re-subscribed to on reconnect.
+func removeTalkgroupConfig(tg uint32) {
+	talkgroupsMu.Lock()
+	defer talkgroupsMu.Unlock()
+
+	for i, existing := range settings.RecTalkgroups {
+		if existing.TG == tg {
+			settings.RecTalkgroups = append(settings.RecTalkgroups[:i], settings.RecTalkgroups[i+1:]...)
+			return
+		}
+	}
+}